@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultMaxHistory bounds orderCore.history when no explicit limit has been set,
+// so long-lived orders don't grow the slice forever.
+const defaultMaxHistory = 100
+
+// maxIdempotencyKeys bounds orderCore.seenKeys the same way defaultMaxHistory
+// bounds history: a long-lived order driven by a store that mints a fresh
+// idempotency key per request would otherwise retain every key it has ever
+// seen. Once the cap is reached, remembering a new key evicts the oldest
+// one, oldest-first (ring-buffer semantics).
+const maxIdempotencyKeys = 1000
+
+// Transition records a single successful state change on an order.
+type Transition struct {
+	From           OrderState
+	To             OrderState
+	Timestamp      time.Time
+	Actor          string
+	Reason         string
+	Metadata       map[string]string
+	IdempotencyKey string
+}
+
+// SetMaxHistory caps the number of Transition entries retained on the order.
+// Once the cap is reached, recording a new transition drops the oldest one
+// (ring-buffer semantics). A negative limit disables the cap; a limit of 0
+// restores the default of defaultMaxHistory.
+func (o *orderCore) SetMaxHistory(n int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.maxHistory = n
+}
+
+// History returns a copy of the order's recorded transitions, oldest first.
+func (o *orderCore) History() []Transition {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	out := make([]Transition, len(o.history))
+	copy(out, o.history)
+	return out
+}
+
+// recordTransition appends a Transition to the order's history, enforcing
+// maxHistory. idempotencyKey, if any, is stamped onto the Transition itself
+// so it survives in a Store's append-only log and Replay can restore
+// dedupe state after a process restart, not just in orderCore.seenKeys.
+// Callers must hold o.mu for writing.
+func (o *orderCore) recordTransition(from, to OrderState, actor, reason string, meta map[string]string, idempotencyKey string) {
+	limit := o.maxHistory
+	if limit == 0 {
+		limit = defaultMaxHistory
+	}
+
+	o.history = append(o.history, Transition{
+		From:           from,
+		To:             to,
+		Timestamp:      time.Now(),
+		Actor:          actor,
+		Reason:         reason,
+		Metadata:       meta,
+		IdempotencyKey: idempotencyKey,
+	})
+
+	if limit > 0 && len(o.history) > limit {
+		o.history = o.history[len(o.history)-limit:]
+	}
+}
+
+// TransitionTo moves the order directly to newState, bypassing the named
+// Event registry used by Trigger. It is validated against the same
+// From/To rules registered via RegisterEvent, runs the matching Exit/Enter
+// hooks, and records the change in the order's history. meta is stored
+// verbatim on the resulting Transition; the conventional "actor" and
+// "reason" keys are pulled out to populate those fields if present.
+//
+// idempotencyKey, if non-empty, is remembered on the order: a later call
+// with the same key is a silent no-op rather than an error, so a retried
+// command (e.g. after a timed-out network call) can't double-apply. The
+// key is also stamped onto the resulting Transition (see recordTransition),
+// so Replay restores the same dedupe state after a crash or restart as
+// long as the transition was appended to a Store — orderCore.seenKeys
+// alone is only an in-process fast path and does not survive a restart.
+func (o *orderCore) TransitionTo(newState OrderState, meta map[string]string, idempotencyKey string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if idempotencyKey != "" {
+		if o.seenKeys != nil && o.seenKeys[idempotencyKey] {
+			return nil
+		}
+	}
+
+	from := o.State
+	if !isAllowedTransition(from, newState) {
+		return fmt.Errorf("order: transition from %q to %q is not allowed", from, newState)
+	}
+
+	for _, fn := range hooksFor(from).exit {
+		if err := fn(o); err != nil {
+			return fmt.Errorf("order: exit hook for %q failed: %w", from, err)
+		}
+	}
+
+	o.State = newState
+
+	for _, fn := range hooksFor(newState).enter {
+		if err := fn(o); err != nil {
+			o.State = from
+			return fmt.Errorf("order: enter hook for %q failed: %w", newState, err)
+		}
+	}
+
+	o.recordTransition(from, newState, meta["actor"], meta["reason"], meta, idempotencyKey)
+
+	if idempotencyKey != "" {
+		o.rememberIdempotencyKey(idempotencyKey)
+	}
+
+	return nil
+}
+
+// rememberIdempotencyKey records key as seen, evicting the oldest
+// remembered key once maxIdempotencyKeys is exceeded. Callers must hold
+// o.mu for writing.
+func (o *orderCore) rememberIdempotencyKey(key string) {
+	if o.seenKeys == nil {
+		o.seenKeys = map[string]bool{}
+	}
+	o.seenKeys[key] = true
+	o.seenKeyOrder = append(o.seenKeyOrder, key)
+
+	if len(o.seenKeyOrder) > maxIdempotencyKeys {
+		oldest := o.seenKeyOrder[0]
+		o.seenKeyOrder = o.seenKeyOrder[1:]
+		delete(o.seenKeys, oldest)
+	}
+}
+
+// isAllowedTransition reports whether any registered event permits moving
+// from from to to. It consumes TransitionTable(), the same declarative
+// table RenderMermaid draws from, so the runtime check and the diagram
+// can't drift apart.
+func isAllowedTransition(from, to OrderState) bool {
+	for _, dest := range TransitionTable()[from] {
+		if dest == to {
+			return true
+		}
+	}
+	return false
+}
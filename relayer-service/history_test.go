@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestOrderCoreTransitionToConcurrentSafe(t *testing.T) {
+	core := &orderCore{ID: "ord-1", State: Pending}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		_ = core.TransitionTo(Processed, nil, "")
+	}()
+	go func() {
+		defer wg.Done()
+		_ = core.currentState()
+	}()
+
+	wg.Wait()
+}
+
+func TestHistoryRingBuffer(t *testing.T) {
+	core := &orderCore{ID: "ord-2", State: Pending}
+	core.SetMaxHistory(2)
+
+	if err := core.TransitionTo(Processed, nil, ""); err != nil {
+		t.Fatalf("Processed: %v", err)
+	}
+	if err := core.TransitionTo(Shipped, nil, ""); err != nil {
+		t.Fatalf("Shipped: %v", err)
+	}
+	if err := core.TransitionTo(Delivered, nil, ""); err != nil {
+		t.Fatalf("Delivered: %v", err)
+	}
+
+	history := core.History()
+	if len(history) != 2 {
+		t.Fatalf("len(history) = %d, want 2", len(history))
+	}
+	if history[0].To != Shipped || history[1].To != Delivered {
+		t.Fatalf("history = %+v, want oldest entry dropped", history)
+	}
+}
+
+func TestTransitionToRejectsIllegalState(t *testing.T) {
+	core := &orderCore{ID: "ord-3", State: Canceled}
+	if err := core.TransitionTo(Shipped, nil, ""); err == nil {
+		t.Fatal("expected error shipping a Canceled order, got nil")
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// defaultSubscriberBuffer sizes a subscription's event channel when the
+// manager hasn't been configured with SetBackpressure.
+const defaultSubscriberBuffer = 32
+
+// BackpressurePolicy controls what a subscription does when its subscriber
+// can't keep up with the rate of transitions.
+type BackpressurePolicy int
+
+const (
+	// BackpressureBlock makes a slow subscriber apply backpressure to the
+	// transition that triggered it: the notifying call blocks until the
+	// subscriber's buffer has room.
+	BackpressureBlock BackpressurePolicy = iota
+	// BackpressureDropOldest discards the oldest buffered event to make
+	// room for the new one, so a slow subscriber can never stall the rest
+	// of the system, at the cost of missing events.
+	BackpressureDropOldest
+)
+
+// subscription is one registered observer: a bounded queue of orders fed by
+// notify and drained by a dedicated worker goroutine running the callback.
+type subscription struct {
+	events chan AnyOrder
+	policy BackpressurePolicy
+}
+
+func newSubscription(bufferSize int, policy BackpressurePolicy) *subscription {
+	if bufferSize <= 0 {
+		bufferSize = defaultSubscriberBuffer
+	}
+	return &subscription{events: make(chan AnyOrder, bufferSize), policy: policy}
+}
+
+func (s *subscription) publish(o AnyOrder) {
+	if s.policy == BackpressureBlock {
+		s.events <- o
+		return
+	}
+
+	for {
+		select {
+		case s.events <- o:
+			return
+		default:
+			select {
+			case <-s.events:
+			default:
+			}
+		}
+	}
+}
+
+func (s *subscription) run(fn func(AnyOrder)) {
+	for o := range s.events {
+		fn(o)
+	}
+}
+
+// OrderManager (OMS) owns a set of tracked orders and lets callers subscribe
+// to their state transitions without the orders themselves knowing about
+// subscribers. Every transition made through the manager is fanned out,
+// asynchronously, to the observers registered for the destination state and
+// to every catch-all subscriber.
+type OrderManager struct {
+	mu         sync.RWMutex
+	orders     map[string]AnyOrder
+	observers  map[OrderState][]*subscription
+	catchAll   []*subscription
+	bufferSize int
+	policy     BackpressurePolicy
+	store      Store
+}
+
+// NewOrderManager creates an empty manager with blocking backpressure and
+// the default subscriber buffer size.
+func NewOrderManager() *OrderManager {
+	return &OrderManager{
+		orders:     map[string]AnyOrder{},
+		observers:  map[OrderState][]*subscription{},
+		bufferSize: defaultSubscriberBuffer,
+		policy:     BackpressureBlock,
+	}
+}
+
+// SetBackpressure configures the buffer size and overflow policy used for
+// subscriptions registered after this call. It does not affect existing
+// subscriptions.
+func (m *OrderManager) SetBackpressure(bufferSize int, policy BackpressurePolicy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bufferSize = bufferSize
+	m.policy = policy
+}
+
+// SetStore attaches store so every transition made through the manager
+// (via Trigger/TransitionTo) is appended to it as an immutable event,
+// right after it's applied in memory and before observers are notified.
+// Pass nil to detach, leaving transitions in-memory only.
+func (m *OrderManager) SetStore(store Store) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = store
+}
+
+// Track registers o with the manager so its transitions can be observed.
+func (m *OrderManager) Track(o AnyOrder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.orders[o.ID()] = o
+}
+
+// SetObserver registers fn to be invoked, on its own worker goroutine,
+// every time a tracked order transitions into state.
+func (m *OrderManager) SetObserver(state OrderState, fn func(AnyOrder)) {
+	m.mu.Lock()
+	sub := newSubscription(m.bufferSize, m.policy)
+	m.observers[state] = append(m.observers[state], sub)
+	m.mu.Unlock()
+
+	go sub.run(fn)
+}
+
+// SubscribeAll registers fn to be invoked, on its own worker goroutine, for
+// every transition made through the manager regardless of destination
+// state.
+func (m *OrderManager) SubscribeAll(fn func(AnyOrder)) {
+	m.mu.Lock()
+	sub := newSubscription(m.bufferSize, m.policy)
+	m.catchAll = append(m.catchAll, sub)
+	m.mu.Unlock()
+
+	go sub.run(fn)
+}
+
+// notify fans a frozen, point-in-time copy of o out to every observer
+// registered for its current state plus every catch-all subscriber. A copy
+// is required rather than o itself: o wraps the same *orderCore that keeps
+// mutating, and dispatch is asynchronous, so a subscriber that runs late
+// would otherwise observe whatever state the order has reached by the time
+// its worker gets to it instead of the state that actually triggered it.
+func (m *OrderManager) notify(o AnyOrder) {
+	m.mu.RLock()
+	subs := make([]*subscription, 0, len(m.observers[o.State()])+len(m.catchAll))
+	subs = append(subs, m.observers[o.State()]...)
+	subs = append(subs, m.catchAll...)
+	m.mu.RUnlock()
+
+	frozen := freezeOrder(o)
+	for _, sub := range subs {
+		sub.publish(frozen)
+	}
+}
+
+// freezeOrder copies o's fields and history into a detached orderCore, so
+// the result no longer reflects later transitions made to o.
+func freezeOrder(o AnyOrder) AnyOrder {
+	return wrapCore(&orderCore{
+		ID:       o.ID(),
+		State:    o.State(),
+		Customer: o.Customer(),
+		Items:    append([]OrderItem(nil), o.Items()...),
+		history:  append([]Transition(nil), o.History()...),
+	})
+}
+
+// Trigger fires event on the tracked order id, and on success appends the
+// resulting Transition to the attached Store (if any) before notifying
+// observers of the resulting state.
+func (m *OrderManager) Trigger(id, event string) error {
+	o, ok := m.order(id)
+	if !ok {
+		return fmt.Errorf("order manager: unknown order %q", id)
+	}
+	if err := o.underlyingCore().Trigger(event); err != nil {
+		return err
+	}
+	if err := m.persist(o); err != nil {
+		return err
+	}
+	m.notify(o)
+	return nil
+}
+
+// TransitionTo moves the tracked order id directly to newState, and on
+// success appends the resulting Transition to the attached Store (if any)
+// before notifying observers of the resulting state. idempotencyKey is
+// forwarded to orderCore.TransitionTo; pass "" if the caller doesn't need
+// retry safety.
+func (m *OrderManager) TransitionTo(id string, newState OrderState, meta map[string]string, idempotencyKey string) error {
+	o, ok := m.order(id)
+	if !ok {
+		return fmt.Errorf("order manager: unknown order %q", id)
+	}
+	core := o.underlyingCore()
+	if idempotencyKey != "" {
+		core.mu.RLock()
+		seen := core.seenKeys[idempotencyKey]
+		core.mu.RUnlock()
+		if seen {
+			return nil
+		}
+	}
+	if err := core.TransitionTo(newState, meta, idempotencyKey); err != nil {
+		return err
+	}
+	if err := m.persist(o); err != nil {
+		return err
+	}
+	m.notify(o)
+	return nil
+}
+
+// persist appends o's most recent Transition to the attached Store, if one
+// is set. The transition has already been applied in memory by the time
+// this runs, so a Store error here means the in-memory and persisted state
+// have diverged and must be surfaced to the caller rather than swallowed.
+func (m *OrderManager) persist(o AnyOrder) error {
+	m.mu.RLock()
+	store := m.store
+	m.mu.RUnlock()
+	if store == nil {
+		return nil
+	}
+
+	history := o.History()
+	if len(history) == 0 {
+		return nil
+	}
+	if err := store.Append(o.ID(), history[len(history)-1]); err != nil {
+		return fmt.Errorf("order manager: persisting transition for %q: %w", o.ID(), err)
+	}
+	return nil
+}
+
+func (m *OrderManager) order(id string) (AnyOrder, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	o, ok := m.orders[id]
+	return o, ok
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOrderManagerFanOutAndCatchAll(t *testing.T) {
+	m := NewOrderManager()
+	o := NewOrder("ord-1", "alice", nil)
+	m.Track(AsAnyOrder(o))
+
+	var mu sync.Mutex
+	var stateHits, allHits []OrderState
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	m.SetObserver(Processed, func(ao AnyOrder) {
+		defer wg.Done()
+		mu.Lock()
+		stateHits = append(stateHits, ao.State())
+		mu.Unlock()
+	})
+	m.SubscribeAll(func(ao AnyOrder) {
+		defer wg.Done()
+		mu.Lock()
+		allHits = append(allHits, ao.State())
+		mu.Unlock()
+	})
+
+	if err := m.Trigger("ord-1", "process"); err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+
+	waitOrTimeout(t, &wg)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(stateHits) != 1 || stateHits[0] != Processed {
+		t.Fatalf("stateHits = %v, want [Processed]", stateHits)
+	}
+	if len(allHits) != 1 || allHits[0] != Processed {
+		t.Fatalf("allHits = %v, want [Processed]", allHits)
+	}
+}
+
+// TestOrderManagerDropOldestNeverBlocksCaller pins a single catch-all
+// subscriber's worker goroutine behind a slow callback, then drives two more
+// transitions through the manager while it's stuck. With
+// BackpressureDropOldest neither Trigger call should block, and the
+// buffered-but-unconsumed Shipped event should be the one dropped in favor
+// of the most recent (Delivered).
+func TestOrderManagerDropOldestNeverBlocksCaller(t *testing.T) {
+	m := NewOrderManager()
+	m.SetBackpressure(1, BackpressureDropOldest)
+
+	o := NewOrder("ord-2", "bob", nil)
+	m.Track(AsAnyOrder(o))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var startOnce sync.Once
+
+	var mu sync.Mutex
+	var delivered []OrderState
+	m.SubscribeAll(func(ao AnyOrder) {
+		startOnce.Do(func() { close(started) })
+		<-release
+		mu.Lock()
+		delivered = append(delivered, ao.State())
+		mu.Unlock()
+	})
+
+	if err := m.Trigger("ord-2", "process"); err != nil { // Pending -> Processed
+		t.Fatalf("process: %v", err)
+	}
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("subscriber worker never picked up the first event")
+	}
+
+	withTimeout(t, "ship", func() error { return m.Trigger("ord-2", "ship") })       // Processed -> Shipped
+	withTimeout(t, "deliver", func() error { return m.Trigger("ord-2", "deliver") }) // Shipped -> Delivered
+
+	close(release)
+
+	deadline := time.After(time.Second)
+	for {
+		mu.Lock()
+		n := len(delivered)
+		mu.Unlock()
+		if n >= 2 {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for subscriber to drain")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(delivered) != 2 || delivered[0] != Processed || delivered[1] != Delivered {
+		t.Fatalf("delivered = %v, want [Processed Delivered] (Shipped dropped)", delivered)
+	}
+}
+
+func withTimeout(t *testing.T, label string, fn func() error) {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("%s: %v", label, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("%s blocked on a slow subscriber under BackpressureDropOldest", label)
+	}
+}
+
+func waitOrTimeout(t *testing.T, wg *sync.WaitGroup) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for observers")
+	}
+}
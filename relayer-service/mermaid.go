@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EventName identifies a registered Event by name.
+type EventName = string
+
+// TransitionTable is the declarative form of the rules built up via
+// RegisterEvent: for every state, which events are legal from it and which
+// state each one leads to. It is the single source of truth consumed by
+// TransitionTo (via isAllowedTransition), Trigger (via the same underlying
+// events registry), and RenderMermaid, so the runtime checks and the
+// diagram can never drift apart.
+func TransitionTable() map[OrderState]map[EventName]OrderState {
+	table := map[OrderState]map[EventName]OrderState{}
+	for _, e := range events {
+		for _, from := range e.froms {
+			if table[from] == nil {
+				table[from] = map[EventName]OrderState{}
+			}
+			table[from][e.Name] = e.to
+		}
+	}
+	return table
+}
+
+// RenderMermaid renders the registered transition rules as a Mermaid
+// flowchart, e.g.:
+//
+//	graph TD
+//	    Pending -->|cancel| Canceled
+//	    Pending -->|process| Processed
+//
+// The output is deterministic: states and events are sorted so the diagram
+// doesn't churn between calls.
+func RenderMermaid() string {
+	table := TransitionTable()
+
+	var sb strings.Builder
+	sb.WriteString("graph TD\n")
+
+	for _, from := range sortedStates(table) {
+		toByName := table[from]
+		names := make([]string, 0, len(toByName))
+		for name := range toByName {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(&sb, "    %s -->|%s| %s\n", from, name, toByName[name])
+		}
+	}
+
+	return sb.String()
+}
+
+func sortedStates(table map[OrderState]map[EventName]OrderState) []OrderState {
+	states := make([]OrderState, 0, len(table))
+	for s := range table {
+		states = append(states, s)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i] < states[j] })
+	return states
+}
+
+// RenderHistoryMermaid renders the actual path this order has taken as a
+// Mermaid flowchart, with each edge labeled by the reason (event name, or
+// the "reason" passed to TransitionTo) that caused it.
+func (o *orderCore) RenderHistoryMermaid() string {
+	history := o.History()
+
+	var sb strings.Builder
+	sb.WriteString("graph TD\n")
+	for _, t := range history {
+		label := t.Reason
+		if label == "" {
+			label = "transition"
+		}
+		fmt.Fprintf(&sb, "    %s -->|%s| %s\n", t.From, label, t.To)
+	}
+
+	return sb.String()
+}
@@ -1,5 +1,10 @@
 package main
 
+import (
+	"fmt"
+	"sync"
+)
+
 type OrderState string
 
 const (
@@ -10,11 +15,17 @@ const (
 	Canceled  OrderState = "Canceled"
 )
 
-type Order struct {
+type orderCore struct {
 	ID       string
 	State    OrderState
 	Customer string
 	Items    []OrderItem
+
+	mu           sync.RWMutex
+	history      []Transition
+	maxHistory   int
+	seenKeys     map[string]bool
+	seenKeyOrder []string
 }
 
 type OrderItem struct {
@@ -23,15 +34,125 @@ type OrderItem struct {
 	Price     float64
 }
 
-func (o *Order) CanTransitionToState(newState OrderState) bool {
-	switch o.State {
-	case Pending:
-		return newState == Processed || newState == Canceled
-	case Processed:
-		return newState == Shipped
-	case Shipped:
-		return newState == Delivered
-	default:
-		return false
+// Event is a named transition between a set of source states and a single
+// destination state, built up with From/To the way http.ServeMux routes are
+// built up with Handle calls.
+type Event struct {
+	Name  string
+	froms []OrderState
+	to    OrderState
+}
+
+// NewEvent starts the definition of a named transition.
+func NewEvent(name string) *Event {
+	return &Event{Name: name}
+}
+
+// From adds one or more source states the event may fire from.
+func (e *Event) From(states ...OrderState) *Event {
+	e.froms = append(e.froms, states...)
+	return e
+}
+
+// To sets the destination state the event transitions to.
+func (e *Event) To(state OrderState) *Event {
+	e.to = state
+	return e
+}
+
+func (e *Event) allowedFrom(state OrderState) bool {
+	for _, s := range e.froms {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+type stateHooks struct {
+	enter []func(*orderCore) error
+	exit  []func(*orderCore) error
+}
+
+var (
+	events       = map[string]*Event{}
+	hooksByState = map[OrderState]*stateHooks{}
+)
+
+// RegisterEvent makes an event available to Trigger and TransitionTo.
+func RegisterEvent(e *Event) {
+	events[e.Name] = e
+}
+
+func hooksFor(state OrderState) *stateHooks {
+	h, ok := hooksByState[state]
+	if !ok {
+		h = &stateHooks{}
+		hooksByState[state] = h
 	}
+	return h
+}
+
+// EnterState registers a callback run after an order transitions into state.
+func EnterState(state OrderState, fn func(*orderCore) error) {
+	h := hooksFor(state)
+	h.enter = append(h.enter, fn)
+}
+
+// ExitState registers a callback run before an order transitions out of state.
+func ExitState(state OrderState, fn func(*orderCore) error) {
+	h := hooksFor(state)
+	h.exit = append(h.exit, fn)
+}
+
+func init() {
+	RegisterEvent(NewEvent("process").From(Pending).To(Processed))
+	RegisterEvent(NewEvent("cancel").From(Pending).To(Canceled))
+	RegisterEvent(NewEvent("ship").From(Processed).To(Shipped))
+	RegisterEvent(NewEvent("deliver").From(Shipped).To(Delivered))
+}
+
+// currentState returns the order's state under a read lock, since State is
+// mutated by Trigger/TransitionTo while holding o.mu for writing.
+func (o *orderCore) currentState() OrderState {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.State
+}
+
+// Trigger fires the named event against the order. It runs the current
+// state's Exit hooks, mutates the state, then runs the new state's Enter
+// hooks. If any hook returns an error the state change is rolled back and
+// the error is returned to the caller, so the order is never left half
+// transitioned.
+func (o *orderCore) Trigger(event string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	e, ok := events[event]
+	if !ok {
+		return fmt.Errorf("order: unknown event %q", event)
+	}
+	if !e.allowedFrom(o.State) {
+		return fmt.Errorf("order: event %q not allowed from state %q", event, o.State)
+	}
+
+	from := o.State
+	for _, fn := range hooksFor(from).exit {
+		if err := fn(o); err != nil {
+			return fmt.Errorf("order: exit hook for %q failed: %w", from, err)
+		}
+	}
+
+	o.State = e.to
+
+	for _, fn := range hooksFor(e.to).enter {
+		if err := fn(o); err != nil {
+			o.State = from
+			return fmt.Errorf("order: enter hook for %q failed: %w", e.to, err)
+		}
+	}
+
+	o.recordTransition(from, e.to, "", e.Name, nil, "")
+	return nil
 }
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrNotFound is returned by Store.Load when no transition log or snapshot
+// has ever been recorded for the given id.
+var ErrNotFound = errors.New("store: order not found")
+
+// Store persists an order's transition log as an append-only event stream,
+// plus an optional snapshot of its current state as a fast-path cache.
+// Refactoring the FSM to go through Append means every accepted transition
+// is an immutable event, which is what makes Replay possible.
+type Store interface {
+	// Load returns the most recent snapshot (nil if none was ever taken)
+	// and the full transition log recorded for id.
+	Load(id string) (AnyOrder, []Transition, error)
+	// Append persists t as the next event for id.
+	Append(id string, t Transition) error
+	// Snapshot persists the current state of o as a fast-path cache
+	// alongside the append-only log.
+	Snapshot(o AnyOrder) error
+}
+
+// memorySnapshot is a point-in-time copy of the fields that make up an
+// order's state. MemoryStore must retain one of these rather than the live
+// AnyOrder it was given, since an AnyOrder wraps a *orderCore that keeps
+// mutating after Snapshot returns.
+type memorySnapshot struct {
+	id       string
+	state    OrderState
+	customer string
+	items    []OrderItem
+}
+
+// MemoryStore is an in-memory Store, useful for tests and for replaying
+// an order's history without standing up a real backing service.
+type MemoryStore struct {
+	mu        sync.Mutex
+	snapshots map[string]memorySnapshot
+	logs      map[string][]Transition
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		snapshots: map[string]memorySnapshot{},
+		logs:      map[string][]Transition{},
+	}
+}
+
+func (s *MemoryStore) Load(id string) (AnyOrder, []Transition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap, hasSnap := s.snapshots[id]
+	log, hasLog := s.logs[id]
+	if !hasSnap && !hasLog {
+		return nil, nil, ErrNotFound
+	}
+
+	out := make([]Transition, len(log))
+	copy(out, log)
+
+	if !hasSnap {
+		return nil, out, nil
+	}
+	core := &orderCore{ID: snap.id, State: snap.state, Customer: snap.customer, Items: snap.items}
+	return wrapCore(core), out, nil
+}
+
+func (s *MemoryStore) Append(id string, t Transition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs[id] = append(s.logs[id], t)
+	return nil
+}
+
+func (s *MemoryStore) Snapshot(o AnyOrder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := make([]OrderItem, len(o.Items()))
+	copy(items, o.Items())
+	s.snapshots[o.ID()] = memorySnapshot{
+		id:       o.ID(),
+		state:    o.State(),
+		customer: o.Customer(),
+		items:    items,
+	}
+	return nil
+}
+
+// JSONFileStore persists each order as a single JSON file named <id>.json
+// under Dir, holding its current state alongside its full transition log.
+// Append also sets the file's State to the appended Transition's To, and
+// Load returns the order held in the file even when Snapshot was never
+// called for id (its Customer and Items are then empty), so Load's first
+// result is not nil once anything has been recorded for id.
+type JSONFileStore struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+func NewJSONFileStore(dir string) *JSONFileStore {
+	return &JSONFileStore{Dir: dir}
+}
+
+type jsonFileRecord struct {
+	ID       string
+	State    OrderState
+	Customer string
+	Items    []OrderItem
+	Log      []Transition
+}
+
+func (s *JSONFileStore) path(id string) string {
+	return filepath.Join(s.Dir, id+".json")
+}
+
+func (s *JSONFileStore) Load(id string) (AnyOrder, []Transition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, err := s.readRecord(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	core := &orderCore{ID: rec.ID, State: rec.State, Customer: rec.Customer, Items: rec.Items}
+	return wrapCore(core), rec.Log, nil
+}
+
+func (s *JSONFileStore) Append(id string, t Transition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, err := s.readRecord(id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	rec.ID = id
+	rec.Log = append(rec.Log, t)
+	rec.State = t.To
+	return s.writeRecord(rec)
+}
+
+func (s *JSONFileStore) Snapshot(o AnyOrder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, err := s.readRecord(o.ID())
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	rec.ID = o.ID()
+	rec.State = o.State()
+	rec.Customer = o.Customer()
+	rec.Items = o.Items()
+	return s.writeRecord(rec)
+}
+
+func (s *JSONFileStore) readRecord(id string) (jsonFileRecord, error) {
+	data, err := os.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return jsonFileRecord{}, ErrNotFound
+	}
+	if err != nil {
+		return jsonFileRecord{}, fmt.Errorf("store: reading %s: %w", id, err)
+	}
+	var rec jsonFileRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return jsonFileRecord{}, fmt.Errorf("store: decoding %s: %w", id, err)
+	}
+	return rec, nil
+}
+
+func (s *JSONFileStore) writeRecord(rec jsonFileRecord) error {
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("store: encoding %s: %w", rec.ID, err)
+	}
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return fmt.Errorf("store: creating %s: %w", s.Dir, err)
+	}
+	return os.WriteFile(s.path(rec.ID), data, 0o644)
+}
+
+// Replay reconstructs the current state of order id by loading its log from
+// store and folding every recorded transition into a fresh order, starting
+// from Pending. It ignores any snapshot, which makes it useful for
+// debugging, audits, and rebuilding projections independently of whatever a
+// (possibly buggy) snapshot claims.
+//
+// It also folds each transition's IdempotencyKey (if any) back into the
+// reconstructed order's dedupe set, so a process that crashed and is
+// replaying its orders from store on startup won't double-apply a retried
+// command whose original attempt it never got to acknowledge.
+func Replay(store Store, id string) (AnyOrder, error) {
+	_, log, err := store.Load(id)
+	if err != nil {
+		return nil, err
+	}
+
+	core := &orderCore{ID: id, State: Pending}
+	for _, t := range log {
+		core.State = t.To
+		core.history = append(core.history, t)
+		if t.IdempotencyKey != "" {
+			core.rememberIdempotencyKey(t.IdempotencyKey)
+		}
+	}
+
+	return wrapCore(core), nil
+}
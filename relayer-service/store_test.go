@@ -0,0 +1,114 @@
+package main
+
+import "testing"
+
+func TestMemoryStoreSnapshotIsPointInTime(t *testing.T) {
+	store := NewMemoryStore()
+	o := NewOrder("ord-1", "alice", nil)
+
+	if err := store.Snapshot(AsAnyOrder(o)); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	if _, err := Process(o); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+
+	snap, _, err := store.Load("ord-1")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if snap.State() != Pending {
+		t.Fatalf("snapshot State() = %v, want Pending (snapshot mutated after the fact)", snap.State())
+	}
+}
+
+// TestOrderManagerPersistsEveryTransition drives transitions entirely
+// through OrderManager.Trigger/TransitionTo (the real path, not hand-called
+// Store.Append) and checks the Store ends up with exactly the events the
+// FSM itself computed, so Replay can reconstruct the same order later.
+func TestOrderManagerPersistsEveryTransition(t *testing.T) {
+	store := NewMemoryStore()
+	m := NewOrderManager()
+	m.SetStore(store)
+
+	o := NewOrder("ord-2", "alice", nil)
+	m.Track(AsAnyOrder(o))
+
+	if err := m.Trigger("ord-2", "process"); err != nil {
+		t.Fatalf("Trigger(process): %v", err)
+	}
+	if err := m.Trigger("ord-2", "ship"); err != nil {
+		t.Fatalf("Trigger(ship): %v", err)
+	}
+	if err := m.TransitionTo("ord-2", Delivered, nil, ""); err != nil {
+		t.Fatalf("TransitionTo(Delivered): %v", err)
+	}
+
+	replayed, err := Replay(store, "ord-2")
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if replayed.State() != Delivered {
+		t.Fatalf("Replay State() = %v, want Delivered", replayed.State())
+	}
+	history := replayed.History()
+	if len(history) != 3 {
+		t.Fatalf("len(Replay History()) = %d, want 3", len(history))
+	}
+	wantTo := []OrderState{Processed, Shipped, Delivered}
+	for i, tr := range history {
+		if tr.To != wantTo[i] {
+			t.Fatalf("history[%d].To = %v, want %v", i, tr.To, wantTo[i])
+		}
+	}
+}
+
+// TestReplayRestoresIdempotencyAcrossRestart simulates a process restart:
+// a fresh orderCore, with no in-memory seenKeys, is rebuilt purely from the
+// Store's log via Replay, and a retried command must still be deduped.
+func TestReplayRestoresIdempotencyAcrossRestart(t *testing.T) {
+	store := NewMemoryStore()
+	m := NewOrderManager()
+	m.SetStore(store)
+
+	o := NewOrder("ord-3", "bob", nil)
+	m.Track(AsAnyOrder(o))
+
+	if err := m.TransitionTo("ord-3", Processed, nil, "req-1"); err != nil {
+		t.Fatalf("TransitionTo: %v", err)
+	}
+
+	replayed, err := Replay(store, "ord-3")
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+
+	core := replayed.underlyingCore()
+	if err := core.TransitionTo(Shipped, nil, "req-1"); err != nil {
+		t.Fatalf("retried TransitionTo after replay: %v", err)
+	}
+	if core.State != Processed {
+		t.Fatalf("State after replayed retry = %v, want Processed (retry must still be deduped)", core.State)
+	}
+}
+
+func TestTransitionToIdempotencyKeyDedupes(t *testing.T) {
+	core := &orderCore{ID: "ord-3", State: Pending}
+
+	if err := core.TransitionTo(Processed, nil, "req-1"); err != nil {
+		t.Fatalf("first TransitionTo: %v", err)
+	}
+	// Simulated retry of the same command: must be a no-op, not an error
+	// and not a second recorded transition.
+	if err := core.TransitionTo(Processed, nil, "req-1"); err != nil {
+		t.Fatalf("retried TransitionTo: %v", err)
+	}
+
+	if core.State != Processed {
+		t.Fatalf("State = %v, want Processed", core.State)
+	}
+	if len(core.History()) != 1 {
+		t.Fatalf("len(History()) = %d, want 1 (retry must not double-apply)", len(core.History()))
+	}
+}
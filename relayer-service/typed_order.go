@@ -0,0 +1,141 @@
+package main
+
+// OrderStatePhantom is implemented only by the phantom marker types below,
+// so Order[S] can never be instantiated with anything else. Each marker is
+// a zero-sized type that carries no data of its own; it exists purely to
+// select, at compile time, which transitions are legal on an Order[S].
+type OrderStatePhantom interface {
+	orderState() OrderState
+	sealed()
+}
+
+type (
+	PendingPhantom   struct{}
+	ProcessedPhantom struct{}
+	ShippedPhantom   struct{}
+	DeliveredPhantom struct{}
+	CanceledPhantom  struct{}
+)
+
+func (PendingPhantom) orderState() OrderState   { return Pending }
+func (ProcessedPhantom) orderState() OrderState { return Processed }
+func (ShippedPhantom) orderState() OrderState   { return Shipped }
+func (DeliveredPhantom) orderState() OrderState { return Delivered }
+func (CanceledPhantom) orderState() OrderState  { return Canceled }
+
+func (PendingPhantom) sealed()   {}
+func (ProcessedPhantom) sealed() {}
+func (ShippedPhantom) sealed()   {}
+func (DeliveredPhantom) sealed() {}
+func (CanceledPhantom) sealed()  {}
+
+// Order[S] pairs the dynamic order record (orderCore) with a phantom type
+// parameter tracking its state at compile time. It carries no data beyond
+// the core, so moving between Order[S] instantiations is a relabeling, not
+// a copy: the underlying core, and its history, are shared.
+type Order[S OrderStatePhantom] struct {
+	core *orderCore
+}
+
+// NewOrder constructs a fresh order in the Pending state.
+func NewOrder(id, customer string, items []OrderItem) Order[PendingPhantom] {
+	return Order[PendingPhantom]{core: &orderCore{ID: id, State: Pending, Customer: customer, Items: items}}
+}
+
+func (o Order[S]) ID() string                   { return o.core.ID }
+func (o Order[S]) Customer() string             { return o.core.Customer }
+func (o Order[S]) Items() []OrderItem           { return o.core.Items }
+func (o Order[S]) State() OrderState            { return o.core.currentState() }
+func (o Order[S]) History() []Transition        { return o.core.History() }
+func (o Order[S]) RenderHistoryMermaid() string { return o.core.RenderHistoryMermaid() }
+
+func (o Order[S]) underlyingCore() *orderCore { return o.core }
+
+// Go has no way to declare a method on one specific instantiation of a
+// generic type (there's no "func (o Order[Pending]) Process()"), so the
+// state-changing operations are free functions instead of methods. Each
+// one only accepts the Order[S] instantiation it is valid from, and
+// returns the instantiation for the state it leads to, so e.g. calling
+// Ship on a Canceled order is a compile error rather than a runtime bool
+// check.
+
+// Process moves a Pending order to Processed.
+func Process(o Order[PendingPhantom]) (Order[ProcessedPhantom], error) {
+	if err := o.core.TransitionTo(Processed, nil, ""); err != nil {
+		return Order[ProcessedPhantom]{}, err
+	}
+	return Order[ProcessedPhantom]{core: o.core}, nil
+}
+
+// CancelPending moves a Pending order to Canceled.
+func CancelPending(o Order[PendingPhantom]) (Order[CanceledPhantom], error) {
+	if err := o.core.TransitionTo(Canceled, nil, ""); err != nil {
+		return Order[CanceledPhantom]{}, err
+	}
+	return Order[CanceledPhantom]{core: o.core}, nil
+}
+
+// Ship moves a Processed order to Shipped.
+func Ship(o Order[ProcessedPhantom]) (Order[ShippedPhantom], error) {
+	if err := o.core.TransitionTo(Shipped, nil, ""); err != nil {
+		return Order[ShippedPhantom]{}, err
+	}
+	return Order[ShippedPhantom]{core: o.core}, nil
+}
+
+// Deliver moves a Shipped order to Delivered.
+func Deliver(o Order[ShippedPhantom]) (Order[DeliveredPhantom], error) {
+	if err := o.core.TransitionTo(Delivered, nil, ""); err != nil {
+		return Order[DeliveredPhantom]{}, err
+	}
+	return Order[DeliveredPhantom]{core: o.core}, nil
+}
+
+// AnyOrder is implemented by every Order[S] instantiation. Code paths that
+// need to hold orders in varying states together — persistence, HTTP
+// handlers — should traffic in AnyOrder rather than a concrete Order[S].
+type AnyOrder interface {
+	ID() string
+	Customer() string
+	Items() []OrderItem
+	State() OrderState
+	History() []Transition
+	RenderHistoryMermaid() string
+
+	underlyingCore() *orderCore
+}
+
+// AsAnyOrder erases the phantom type parameter of o, for storing it
+// alongside Order values of other states.
+func AsAnyOrder[S OrderStatePhantom](o Order[S]) AnyOrder {
+	return o
+}
+
+// OrderAs type-switches a dynamically-held AnyOrder back to a concrete,
+// compile-time-checked Order[S]. It reports false if the order is not
+// currently in the state S represents.
+func OrderAs[S OrderStatePhantom](o AnyOrder) (Order[S], bool) {
+	var want S
+	if o.State() != want.orderState() {
+		return Order[S]{}, false
+	}
+	return Order[S]{core: o.underlyingCore()}, true
+}
+
+// wrapCore erases core's state into the matching Order[S] instantiation and
+// returns it as an AnyOrder. It's used by code, like Store, that only
+// learns an order's state at runtime.
+func wrapCore(core *orderCore) AnyOrder {
+	switch core.State {
+	case Processed:
+		return AsAnyOrder(Order[ProcessedPhantom]{core: core})
+	case Shipped:
+		return AsAnyOrder(Order[ShippedPhantom]{core: core})
+	case Delivered:
+		return AsAnyOrder(Order[DeliveredPhantom]{core: core})
+	case Canceled:
+		return AsAnyOrder(Order[CanceledPhantom]{core: core})
+	default:
+		return AsAnyOrder(Order[PendingPhantom]{core: core})
+	}
+}